@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// WeightedCode is one entry of a weighted status-code distribution, e.g.
+// the "500:3" in "error.codes=500:3,502:1,504:1".
+type WeightedCode struct {
+	Code   int
+	Weight int
+}
+
+// FaultRule describes the chaos behaviour to apply to requests matching
+// PathPattern (a path.Match glob) and, if set, one of Methods.
+type FaultRule struct {
+	PathPattern     string
+	Methods         []string
+	Latency         time.Duration
+	LatencyJitter   time.Duration
+	ErrorRate       float64
+	ErrorCodes      []WeightedCode
+	ResetRate       float64
+	DripBytesPerSec int64
+	GarbleRate      float64
+}
+
+func (r FaultRule) matches(req *http.Request) bool {
+	ok, err := path.Match(r.PathPattern, req.URL.Path)
+	if err != nil || !ok {
+		return false
+	}
+	if len(r.Methods) == 0 {
+		return true
+	}
+	for _, m := range r.Methods {
+		if strings.EqualFold(m, req.Method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r FaultRule) pickErrorCode(rng *lockedRand) int {
+	total := 0
+	for _, wc := range r.ErrorCodes {
+		total += wc.Weight
+	}
+	if total == 0 {
+		return http.StatusInternalServerError
+	}
+	n := rng.IntN(total)
+	for _, wc := range r.ErrorCodes {
+		if n < wc.Weight {
+			return wc.Code
+		}
+		n -= wc.Weight
+	}
+	return r.ErrorCodes[len(r.ErrorCodes)-1].Code
+}
+
+// ParseFaultRules parses the semicolon-separated rule-file format, one rule
+// per non-empty, non-comment line:
+//
+//	path=/api/*; method=GET,POST; latency=200ms+-100ms; error.rate=0.1; error.codes=500:3,502:1,504:1; reset.rate=0.02; drip.rate=64; garble.rate=0.01
+//
+// latency jitter may be written with "+-" or the literal "±".
+func ParseFaultRules(r io.Reader) ([]FaultRule, error) {
+	var rules []FaultRule
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := parseFaultRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("fault rule %q: %w", line, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func parseFaultRule(line string) (FaultRule, error) {
+	var rule FaultRule
+	for _, part := range strings.Split(line, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return rule, fmt.Errorf("malformed clause %q", part)
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		var err error
+		switch k {
+		case "path":
+			rule.PathPattern = v
+		case "method":
+			rule.Methods = strings.Split(v, ",")
+		case "latency":
+			rule.Latency, rule.LatencyJitter, err = parseLatency(v)
+		case "error.rate":
+			rule.ErrorRate, err = strconv.ParseFloat(v, 64)
+		case "error.codes":
+			rule.ErrorCodes, err = parseWeightedCodes(v)
+		case "reset.rate":
+			rule.ResetRate, err = strconv.ParseFloat(v, 64)
+		case "drip.rate":
+			rule.DripBytesPerSec, err = strconv.ParseInt(v, 10, 64)
+		case "garble.rate":
+			rule.GarbleRate, err = strconv.ParseFloat(v, 64)
+		default:
+			err = fmt.Errorf("unknown clause key %q", k)
+		}
+		if err != nil {
+			return rule, err
+		}
+	}
+	if rule.PathPattern == "" {
+		return rule, fmt.Errorf("missing path=")
+	}
+	return rule, nil
+}
+
+func parseLatency(v string) (base, jitter time.Duration, err error) {
+	sep := "+-"
+	if strings.Contains(v, "±") {
+		sep = "±"
+	}
+	main, jit, found := strings.Cut(v, sep)
+	base, err = time.ParseDuration(main)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !found {
+		return base, 0, nil
+	}
+	jitter, err = time.ParseDuration(jit)
+	return base, jitter, err
+}
+
+func parseWeightedCodes(v string) ([]WeightedCode, error) {
+	var codes []WeightedCode
+	for _, entry := range strings.Split(v, ",") {
+		codeStr, weightStr, ok := strings.Cut(entry, ":")
+		code, err := strconv.Atoi(codeStr)
+		if err != nil {
+			return nil, err
+		}
+		weight := 1
+		if ok {
+			weight, err = strconv.Atoi(weightStr)
+			if err != nil {
+				return nil, err
+			}
+		}
+		codes = append(codes, WeightedCode{Code: code, Weight: weight})
+	}
+	return codes, nil
+}
+
+// LoadFaultRules reads and parses a fault rule file from disk.
+func LoadFaultRules(filename string) ([]FaultRule, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseFaultRules(f)
+}
+
+// FaultMiddleware wraps next with per-path fault injection driven by
+// rules, which is read fresh on every request so rules can be swapped at
+// runtime (e.g. by a scenario's http-fault track). The first matching
+// rule is applied; requests matching no rule pass through untouched. rng
+// is shared across all requests so a given -rand.seed1/2 reproduces the
+// same sequence of fault decisions run to run.
+func FaultMiddleware(rules *atomic.Pointer[[]FaultRule], rng *lockedRand, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if current := rules.Load(); current != nil {
+			for _, rule := range *current {
+				if rule.matches(r) {
+					if applyFault(rule, rng, w, r) {
+						return
+					}
+					break
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// applyFault injects latency/errors/resets/garbling for a single matched
+// rule and reports whether it already wrote a response (true) or the
+// request should fall through to the real handler (false).
+func applyFault(rule FaultRule, rng *lockedRand, w http.ResponseWriter, r *http.Request) bool {
+	if rule.Latency > 0 || rule.LatencyJitter > 0 {
+		d := rule.Latency
+		if rule.LatencyJitter > 0 {
+			d += time.Duration(rng.Int64N(2*int64(rule.LatencyJitter))) - rule.LatencyJitter
+		}
+		time.Sleep(max(0, d))
+	}
+
+	if rule.ResetRate > 0 && rng.Float64() < rule.ResetRate {
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return true
+		}
+		conn, _, err := hijacker.Hijack()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return true
+		}
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			tcp.SetLinger(0)
+		}
+		conn.Close()
+		return true
+	}
+
+	if rule.ErrorRate > 0 && rng.Float64() < rule.ErrorRate {
+		w.WriteHeader(rule.pickErrorCode(rng))
+		return true
+	}
+
+	if rule.DripBytesPerSec > 0 {
+		flusher, _ := w.(http.Flusher)
+		w.WriteHeader(http.StatusOK)
+		const chunk = 64
+		remaining := int64(4096)
+		for remaining > 0 {
+			n := min(remaining, chunk)
+			w.Write(randomBytes(rng, int(n)))
+			if flusher != nil {
+				flusher.Flush()
+			}
+			remaining -= n
+			time.Sleep(time.Duration(float64(n) / float64(rule.DripBytesPerSec) * float64(time.Second)))
+		}
+		return true
+	}
+
+	if rule.GarbleRate > 0 && rng.Float64() < rule.GarbleRate {
+		w.Header().Set("Content-Length", "4096")
+		w.WriteHeader(http.StatusOK)
+		w.Write(randomBytes(rng, 16))
+		return true
+	}
+
+	return false
+}
+
+func randomBytes(rng *lockedRand, n int) []byte {
+
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(rng.IntN(256))
+	}
+	return b
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// p2Quantile is a streaming estimator for a single quantile using the P²
+// algorithm (Jain & Chlamtac, 1985): constant memory regardless of how
+// many samples are observed.
+type p2Quantile struct {
+	p       float64
+	initial []float64
+	n       [5]int
+	npos    [5]float64
+	dn      [5]float64
+	heights [5]float64
+	count   int
+}
+
+func newP2Quantile(p float64) *p2Quantile {
+	return &p2Quantile{p: p, dn: [5]float64{0, p / 2, p, (1 + p) / 2, 1}}
+}
+
+func (q *p2Quantile) Add(x float64) {
+	q.count++
+
+	if q.count <= 5 {
+		q.initial = append(q.initial, x)
+		if q.count == 5 {
+			sort.Float64s(q.initial)
+			for i := 0; i < 5; i++ {
+				q.heights[i] = q.initial[i]
+				q.n[i] = i + 1
+			}
+			q.npos = [5]float64{1, 1 + 2*q.p, 1 + 4*q.p, 3 + 2*q.p, 5}
+		}
+		return
+	}
+
+	var k int
+	switch {
+	case x < q.heights[0]:
+		q.heights[0] = x
+		k = 0
+	case x >= q.heights[4]:
+		q.heights[4] = x
+		k = 3
+	default:
+		for i := 0; i < 4; i++ {
+			if x < q.heights[i+1] {
+				k = i
+				break
+			}
+		}
+	}
+
+	for i := k + 1; i < 5; i++ {
+		q.n[i]++
+	}
+	for i := 0; i < 5; i++ {
+		q.npos[i] += q.dn[i]
+	}
+
+	for i := 1; i <= 3; i++ {
+		d := q.npos[i] - float64(q.n[i])
+		if (d >= 1 && q.n[i+1]-q.n[i] > 1) || (d <= -1 && q.n[i-1]-q.n[i] < -1) {
+			sign := 1
+			if d < 0 {
+				sign = -1
+			}
+			qp := q.parabolic(i, sign)
+			if q.heights[i-1] < qp && qp < q.heights[i+1] {
+				q.heights[i] = qp
+			} else {
+				q.heights[i] = q.linear(i, sign)
+			}
+			q.n[i] += sign
+		}
+	}
+}
+
+func (q *p2Quantile) parabolic(i, d int) float64 {
+	fd := float64(d)
+	return q.heights[i] + fd/float64(q.n[i+1]-q.n[i-1])*
+		(float64(q.n[i]-q.n[i-1]+d)*(q.heights[i+1]-q.heights[i])/float64(q.n[i+1]-q.n[i])+
+			float64(q.n[i+1]-q.n[i]-d)*(q.heights[i]-q.heights[i-1])/float64(q.n[i]-q.n[i-1]))
+}
+
+func (q *p2Quantile) linear(i, d int) float64 {
+	return q.heights[i] + float64(d)*(q.heights[i+d]-q.heights[i])/float64(q.n[i+d]-q.n[i])
+}
+
+func (q *p2Quantile) Value() float64 {
+	switch {
+	case q.count == 0:
+		return 0
+	case q.count < 5:
+		sorted := append([]float64(nil), q.initial...)
+		sort.Float64s(sorted)
+		return sorted[int(q.p*float64(len(sorted)-1))]
+	default:
+		return q.heights[2]
+	}
+}
+
+// welford is a streaming mean estimator (Welford's algorithm), the O(1)
+// companion to p2Quantile for the "mean" half of the latency summary.
+type welford struct {
+	count int64
+	mean  float64
+}
+
+func (w *welford) Add(x float64) {
+	w.count++
+	w.mean += (x - w.mean) / float64(w.count)
+}
+
+// loadResult is one outbound request's outcome, fed into LoadStats.
+type loadResult struct {
+	status  int
+	err     error
+	latency time.Duration
+	bytes   int64
+}
+
+// LoadStats accumulates outbound load-generator results with O(1) memory
+// regardless of request count.
+type LoadStats struct {
+	mu              sync.Mutex
+	total           int64
+	statusClasses   map[string]int64
+	transportErrors int64
+	bytesRead       int64
+	latencyMS       welford
+	p50, p95, p99   *p2Quantile
+	start           time.Time
+}
+
+func newLoadStats() *LoadStats {
+	return &LoadStats{
+		statusClasses: map[string]int64{},
+		p50:           newP2Quantile(0.50),
+		p95:           newP2Quantile(0.95),
+		p99:           newP2Quantile(0.99),
+		start:         time.Now(),
+	}
+}
+
+func (s *LoadStats) Record(r loadResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.total++
+	if r.err != nil {
+		s.transportErrors++
+	} else {
+		s.statusClasses[fmt.Sprintf("%dxx", r.status/100)]++
+		s.bytesRead += r.bytes
+	}
+
+	ms := float64(r.latency.Microseconds()) / 1000
+	s.latencyMS.Add(ms)
+	s.p50.Add(ms)
+	s.p95.Add(ms)
+	s.p99.Add(ms)
+}
+
+// LoadSummary is the JSON shape printed on exit and logged periodically.
+type LoadSummary struct {
+	TotalRequests   int64            `json:"total_requests"`
+	StatusClasses   map[string]int64 `json:"status_classes"`
+	TransportErrors int64            `json:"transport_errors"`
+	BytesRead       int64            `json:"bytes_read"`
+	MeanLatencyMS   float64          `json:"mean_latency_ms"`
+	P50LatencyMS    float64          `json:"p50_latency_ms"`
+	P95LatencyMS    float64          `json:"p95_latency_ms"`
+	P99LatencyMS    float64          `json:"p99_latency_ms"`
+	Elapsed         time.Duration    `json:"elapsed"`
+	RequestsPerSec  float64          `json:"requests_per_sec"`
+	BytesPerSec     float64          `json:"bytes_per_sec"`
+}
+
+func (s *LoadStats) Summary() LoadSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elapsed := time.Since(s.start)
+	classes := make(map[string]int64, len(s.statusClasses))
+	for k, v := range s.statusClasses {
+		classes[k] = v
+	}
+
+	summary := LoadSummary{
+		TotalRequests:   s.total,
+		StatusClasses:   classes,
+		TransportErrors: s.transportErrors,
+		BytesRead:       s.bytesRead,
+		MeanLatencyMS:   s.latencyMS.mean,
+		P50LatencyMS:    s.p50.Value(),
+		P95LatencyMS:    s.p95.Value(),
+		P99LatencyMS:    s.p99.Value(),
+		Elapsed:         elapsed.Round(time.Millisecond),
+	}
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		summary.RequestsPerSec = float64(s.total) / seconds
+		summary.BytesPerSec = float64(s.bytesRead) / seconds
+	}
+	return summary
+}
+
+// loadRequestBody resolves -load.body/-load.body.file into the bytes sent
+// with every request; body.file takes precedence when both are set.
+func loadRequestBody(inline, file string) ([]byte, error) {
+	if file != "" {
+		return os.ReadFile(file)
+	}
+	return []byte(inline), nil
+}
+
+func doLoadRequest(client *http.Client, method, url string, body []byte) loadResult {
+	start := time.Now()
+
+	var reqBody io.Reader
+	if len(body) > 0 {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return loadResult{err: err, latency: time.Since(start)}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return loadResult{err: err, latency: time.Since(start)}
+	}
+	defer resp.Body.Close()
+
+	n, _ := io.Copy(io.Discard, resp.Body)
+	return loadResult{status: resp.StatusCode, latency: time.Since(start), bytes: n}
+}
+
+// runLoadGenerator drives flags.LoadConcurrency workers against
+// flags.LoadURL for flags.LoadDuration, optionally paced to flags.LoadRPS
+// requests/sec via a token-bucket ticker, logging running stats through
+// the existing zerolog pipeline and printing a final JSON summary.
+func runLoadGenerator(flags Flags) {
+	if flags.LoadURL == "" {
+		logger.Fatal().Msg("load subcommand requires -load.url")
+	}
+
+	body, err := loadRequestBody(flags.LoadBody, flags.LoadBodyFile)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("could not read load.body.file")
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{DisableKeepAlives: !flags.LoadKeepalive},
+		Timeout:   flags.LoadTimeout,
+	}
+
+	stats := newLoadStats()
+
+	var tokens <-chan time.Time
+	if flags.LoadRPS > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / flags.LoadRPS))
+		defer ticker.Stop()
+		tokens = ticker.C
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), flags.LoadDuration)
+	defer cancel()
+
+	logger.Info().Str("url", flags.LoadURL).Int("concurrency", flags.LoadConcurrency).
+		Dur("duration", flags.LoadDuration).Msg("load: starting")
+
+	var wg sync.WaitGroup
+	for range max(1, flags.LoadConcurrency) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if tokens != nil {
+					select {
+					case <-ctx.Done():
+						return
+					case <-tokens:
+					}
+				}
+				select {
+				case <-ctx.Done():
+					return
+				default:
+				}
+				stats.Record(doLoadRequest(client, flags.LoadMethod, flags.LoadURL, body))
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	report := time.NewTicker(5 * time.Second)
+	defer report.Stop()
+	for finished := false; !finished; {
+		select {
+		case <-report.C:
+			logger.Info().Interface("stats", stats.Summary()).Msg("load: progress")
+		case <-done:
+			finished = true
+		}
+	}
+
+	summary := stats.Summary()
+	logger.Info().Interface("summary", summary).Msg("load: finished")
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(summary)
+}
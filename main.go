@@ -9,6 +9,7 @@ import (
 	"os/signal"
 	"runtime"
 	"strconv"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -41,6 +42,7 @@ func rootHandler(w http.ResponseWriter, r *http.Request) {
 	logger.Info().Msg("/ requested")
 	fmt.Fprintf(w, "numcpu: %v\n", runtime.NumCPU())
 	fmt.Fprintf(w, "maxprocs: %v\n", runtime.GOMAXPROCS(0))
+	fmt.Fprintf(w, "memload.bytes: %v\n", memLoadBytes.Load())
 }
 
 // Flags .
@@ -58,6 +60,34 @@ type Flags struct {
 	CPULoadWorkers  int
 	CPULoadDuration time.Duration
 
+	ProbeLive    ProbeSettings
+	ProbeReady   ProbeSettings
+	ProbeStartup ProbeSettings
+
+	MemLoadEnable   bool
+	MemLoadWorkers  int
+	MemLoadMax      ByteSizeFlag
+	MemLoadGrowRate ByteSizeFlag
+	MemLoadPlan     string
+
+	WebFaultsFile string
+
+	GracefulEnable bool
+	GracefulHammer time.Duration
+
+	ScenarioEnable bool
+	ScenarioFile   string
+
+	LoadURL         string
+	LoadMethod      string
+	LoadConcurrency int
+	LoadRPS         float64
+	LoadDuration    time.Duration
+	LoadBody        string
+	LoadBodyFile    string
+	LoadTimeout     time.Duration
+	LoadKeepalive   bool
+
 	RandSeed1 uint64
 	RandSeed2 uint64
 }
@@ -76,9 +106,39 @@ func (f *Flags) Register(fs *flag.FlagSet) {
 
 	fs.BoolVar(&f.IgnoreSignals, "signals.ignore", false, "ignore shutdown signals")
 
-	fs.BoolVar(&f.CPUloadEnable, "cpuload.enable", false, "enable cpu load generator")
+	fs.BoolVar(&f.CPUloadEnable, "cpuload.enable", false, "enable cpu load generator, running the built-in plan through the scenario engine (mutually exclusive with scenario.enable)")
 	fs.IntVar(&f.CPULoadWorkers, "cpuload.workers", 1, "number of concurrent goroutines, won't go over max")
 
+	f.ProbeLive.register(fs, "live")
+	f.ProbeReady.register(fs, "ready")
+	f.ProbeStartup.register(fs, "startup")
+
+	fs.BoolVar(&f.MemLoadEnable, "memload.enable", false, "enable memory load generator")
+	fs.IntVar(&f.MemLoadWorkers, "memload.workers", 1, "number of concurrent memload plan runners")
+	f.MemLoadMax = ByteSizeFlag(0)
+	fs.Var(&f.MemLoadMax, "memload.max", "cap on bytes held by the memory load generator, 0=unlimited")
+	f.MemLoadGrowRate = ByteSizeFlag(16 << 20)
+	fs.Var(&f.MemLoadGrowRate, "memload.grow.rate", "bytes allocated per second while growing toward a target")
+	fs.StringVar(&f.MemLoadPlan, "memload.plan", "inline", "memload plan: \"inline\" for the built-in plan, or a path to a plan file")
+
+	fs.StringVar(&f.WebFaultsFile, "web.faults.file", "", "path to a per-path HTTP fault injection rule file")
+
+	fs.BoolVar(&f.GracefulEnable, "graceful.enable", false, "on SIGHUP, exec a replacement process and hand off the listener, draining in-flight requests")
+	fs.DurationVar(&f.GracefulHammer, "graceful.hammer", 30*time.Second, "how long to wait for in-flight requests to drain before forcing the old process to close")
+
+	fs.BoolVar(&f.ScenarioEnable, "scenario.enable", false, "run a scenario timeline of concurrent cpu/mem/http-fault/exit/signal-ignore-window/probe-flip tracks")
+	fs.StringVar(&f.ScenarioFile, "scenario.file", "inline", "scenario timeline: \"inline\" for the built-in cpu-only plan, or a path to a scenario file (.json, or the line-based format)")
+
+	fs.StringVar(&f.LoadURL, "load.url", "", "target URL for the 'load' subcommand's outbound HTTP load generator")
+	fs.StringVar(&f.LoadMethod, "load.method", "GET", "HTTP method used by the 'load' subcommand")
+	fs.IntVar(&f.LoadConcurrency, "load.concurrency", 1, "number of concurrent 'load' subcommand workers")
+	fs.Float64Var(&f.LoadRPS, "load.rps", 0, "target requests/sec across all 'load' subcommand workers, 0=unlimited")
+	fs.DurationVar(&f.LoadDuration, "load.duration", 30*time.Second, "how long the 'load' subcommand runs")
+	fs.StringVar(&f.LoadBody, "load.body", "", "inline request body for the 'load' subcommand")
+	fs.StringVar(&f.LoadBodyFile, "load.body.file", "", "path to a request body file for the 'load' subcommand, takes precedence over load.body")
+	fs.DurationVar(&f.LoadTimeout, "load.timeout", 10*time.Second, "per-request timeout for the 'load' subcommand")
+	fs.BoolVar(&f.LoadKeepalive, "load.keepalive", true, "reuse connections between 'load' subcommand requests")
+
 	fs.Uint64Var(&f.RandSeed1, "rand.seed1", rand.Uint64(), "seed1 for random generator")
 	fs.Uint64Var(&f.RandSeed2, "rand.seed2", rand.Uint64(), "seed2 for random generator")
 
@@ -162,6 +222,9 @@ func main() {
 			os.Exit(flags.ExitCode)
 		case "beat":
 			log.Info().Msg("start as beat")
+		case "load":
+			runLoadGenerator(flags)
+			os.Exit(flags.ExitCode)
 		default:
 			fmt.Println("unknown subcommand:", fs.Arg(0))
 			os.Exit(1)
@@ -183,33 +246,135 @@ func main() {
 		}
 	}
 
+	// liveProbe/readyProbe/startupProbe and scenarioFaultRules stay nil when
+	// web.enable is false; a scenario's probe-flip/http-fault steps detect
+	// that and log-and-skip instead of the whole engine silently doing
+	// nothing (see the ScenarioEnable block below, which runs regardless of
+	// web.enable since its cpu/mem/exit/signal-ignore-window tracks don't
+	// need the web subsystem at all).
+	var liveProbe, readyProbe, startupProbe *ProbeState
+	var scenarioFaultRules *atomic.Pointer[[]FaultRule]
+
 	if flags.WebEnable {
+		ln, err := resolveListener(flags.WebListen)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("could not create listener")
+		}
+
+		probeRand := newLockedRand(rand.New(rand.NewPCG(flags.RandSeed1, flags.RandSeed2)))
+		liveProbe = NewProbeState("live", flags.ProbeLive, probeRand)
+		readyProbe = NewProbeState("ready", flags.ProbeReady, probeRand)
+		startupProbe = NewProbeState("startup", flags.ProbeStartup, probeRand)
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/", rootHandler)
+		mux.HandleFunc("/healthz", liveProbe.handler())
+		mux.HandleFunc("/livez", liveProbe.handler())
+		mux.HandleFunc("/readyz", readyProbe.handler())
+		mux.HandleFunc("/startupz", startupProbe.handler())
+		mux.HandleFunc("/probe/status", probeStatusHandler(liveProbe, readyProbe, startupProbe))
+		mux.HandleFunc("/exit/", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			codeStr := r.URL.Query().Get("code")
+			code, err := strconv.ParseInt(codeStr, 10, 64)
+			if err != nil || code < 0 || code > 127 {
+				code = 1
+			}
+			logger.Info().Msg("exit on http request")
+			os.Exit(int(code))
+		})
+		var faultRules atomic.Pointer[[]FaultRule]
+		if flags.WebFaultsFile != "" {
+			rules, err := LoadFaultRules(flags.WebFaultsFile)
+			if err != nil {
+				logger.Fatal().Err(err).Msg("could not load fault rules")
+			}
+			logger.Info().Int("rules", len(rules)).Msg("fault injection enabled")
+			faultRules.Store(&rules)
+		}
+		scenarioFaultRules = &faultRules
+		faultRand := newLockedRand(rand.New(rand.NewPCG(flags.RandSeed1, flags.RandSeed2)))
+		handler := FaultMiddleware(&faultRules, faultRand, mux)
+
+		srv := &http.Server{Handler: handler}
+
 		go func() {
-			mux := http.NewServeMux()
-			mux.HandleFunc("/", rootHandler)
-			mux.HandleFunc("/exit/", func(w http.ResponseWriter, r *http.Request) {
-				w.WriteHeader(http.StatusOK)
-				codeStr := r.URL.Query().Get("code")
-				code, err := strconv.ParseInt(codeStr, 10, 64)
-				if err != nil || code < 0 || code > 127 {
-					code = 1
-				}
-				logger.Info().Msg("exit on http request")
-				os.Exit(int(code))
-			})
 			time.Sleep(effectiveSettings.WebDelay)
 			logger.Info().Msg("listen")
-			if err := http.ListenAndServe(flags.WebListen, mux); err != nil {
+			if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
 				logger.Fatal().Err(err).Msg("http listen error")
 			}
 		}()
+
+		if flags.GracefulEnable {
+			if flags.IgnoreSignals {
+				logger.Warn().Msg("graceful.enable has no effect while signals.ignore is set")
+			} else {
+				sighup := make(chan os.Signal, 1)
+				signal.Notify(sighup, syscall.SIGHUP)
+				go startGracefulRestart(sighup, srv, ln, flags.GracefulHammer)
+			}
+		}
+	}
+
+	if flags.ScenarioEnable {
+		scenario, err := LoadScenario(flags.ScenarioFile)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("could not load scenario")
+		}
+		logger.Info().Int("tracks", len(scenario.Tracks)).Msg("scenario enabled")
+		RunScenario(scenario, ScenarioDeps{
+			Rand: rand.New(rand.NewPCG(flags.RandSeed1, flags.RandSeed2)),
+			Probes: map[string]*ProbeState{
+				"live": liveProbe, "ready": readyProbe, "startup": startupProbe,
+			},
+			FaultRules: scenarioFaultRules,
+			ExitCode:   flags.ExitCode,
+		})
+	}
+
+	if flags.CPUloadEnable && flags.ScenarioEnable {
+		logger.Fatal().Msg("cpuload.enable and scenario.enable are mutually exclusive; give -scenario.file a cpu track instead")
 	}
 
 	if flags.CPUloadEnable {
 		nWorkers := max(1, min(runtime.GOMAXPROCS(0), flags.CPULoadWorkers))
 		logger.Info().Int("workers", nWorkers).Msg("starting cpu load")
+		cpuScenario := Scenario{Tracks: make([]ScenarioTrack, nWorkers)}
+		for i := range cpuScenario.Tracks {
+			cpuScenario.Tracks[i] = defaultScenario().Tracks[0]
+		}
+		RunScenario(cpuScenario, ScenarioDeps{})
+	}
+
+	if flags.MemLoadEnable {
+		plan, err := loadMemPlan(flags.MemLoadPlan)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("could not load memload plan")
+		}
+
+		release := make(chan struct{})
+		if !flags.IgnoreSignals {
+			sigs := []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+			if !flags.GracefulEnable {
+				// SIGHUP is graceful.enable's restart trigger; leave it to
+				// startGracefulRestart instead of racing a hard exit here.
+				sigs = append(sigs, syscall.SIGHUP)
+			}
+			c := make(chan os.Signal, 1)
+			signal.Notify(c, sigs...)
+			go func() {
+				s := <-c
+				logger.Info().Stringer("signal", s).Msg("releasing memload on signal")
+				close(release)
+				os.Exit(0)
+			}()
+		}
+
+		nWorkers := max(1, flags.MemLoadWorkers)
+		logger.Info().Int("workers", nWorkers).Msg("starting mem load")
 		for range nWorkers {
-			go startCPULoad()
+			go startMemLoad(plan, int64(flags.MemLoadMax), int64(flags.MemLoadGrowRate), release)
 		}
 	}
 
@@ -218,83 +383,24 @@ func main() {
 	}
 }
 
-func startCPULoad() {
+// Action is one step of a CPU load plan: busy-loop at Percent utilization
+// for Duration, or sleep for Duration if Percent is 0.
+type Action struct {
+	Percent  int
+	Duration time.Duration
+}
+
+// startCPULoad runs plan once, logging and busy-looping or sleeping
+// through each step in turn. The built-in chaos program lives in
+// defaultCPUPlan; -scenario.file can replace it with a custom timeline.
+func startCPULoad(plan []Action) {
 	testID := xid.New()
 	logger := logger.With().Str("cpuload.id", testID.String()).Logger()
 	logger.Info().Msg("load test starts")
 	defer logger.Info().Msg("load test ended")
 
-	const normal = 6 * time.Minute
-	const burst = 30 * time.Second
-	const sleep = 6 * time.Minute
-	const shortSleep = 30 * time.Second
-	const longSleep = 10 * time.Minute
-
-	type Action struct {
-		Percent  int
-		Duration time.Duration
-	}
-
-	tests := []Action{
-		{Duration: burst, Percent: 90},
-		{Duration: shortSleep},
-		{Duration: burst, Percent: 90},
-		{Duration: shortSleep},
-		{Duration: normal, Percent: 10},
-		{Duration: sleep},
-		{Duration: normal, Percent: 20},
-		{Duration: sleep},
-		{Duration: normal, Percent: 30},
-		{Duration: sleep},
-		{Duration: normal, Percent: 40},
-		{Duration: sleep},
-		{Duration: normal, Percent: 50},
-		{Duration: sleep},
-		{Duration: normal, Percent: 60},
-		{Duration: sleep},
-		{Duration: normal, Percent: 70},
-		{Duration: sleep},
-		{Duration: burst, Percent: 90},
-		{Duration: shortSleep},
-		{Duration: burst, Percent: 90},
-		{Duration: sleep},
-		{Duration: normal, Percent: 70},
-		{Duration: normal, Percent: 50},
-		{Duration: normal, Percent: 20},
-		{Duration: shortSleep},
-		{Duration: burst, Percent: 90},
-		{Duration: longSleep},
-		{Duration: burst, Percent: 90},
-		{Duration: longSleep},
-		{Duration: burst, Percent: 90},
-		{Duration: sleep},
-		{Duration: burst, Percent: 50},
-		{Duration: sleep},
-		{Duration: burst, Percent: 80},
-		{Duration: sleep},
-		{Duration: burst, Percent: 70},
-		{Duration: longSleep},
-	}
-
-	// {
-	// 	var sb strings.Builder
-	// 	var cum time.Duration
-	// 	for i, action := range tests {
-	// 		sb.WriteRune('\n')
-	// 		fmt.Fprintf(&sb, "%03d %s ", i, cum.String())
-	// 		if action.Percent == 0 {
-	// 			fmt.Fprintf(&sb, "sleep for %s", action.Duration)
-	// 			continue
-	// 		}
-	// 		sb.WriteString(fmt.Sprintf("use %v%% cpu for %s", action.Percent, action.Duration.String()))
-	// 		cum += action.Duration
-
-	// 	}
-
-	// 	log.Info().Msg("test plan:" + sb.String())
-	// }
 	t0 := time.Now()
-	for i, action := range tests {
+	for i, action := range plan {
 		logger := logger.With().Int("test.#", i).Dur("test.time", time.Now().Sub(t0).Round(100*time.Millisecond)).Logger()
 		if action.Percent == 0 {
 			logger.Info().Msg("Sleep for " + action.Duration.String())
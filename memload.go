@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// memLoadBytes is the approximate number of heap bytes currently held across
+// all startMemLoad workers, updated via relative Add() calls so concurrent
+// workers sum correctly instead of clobbering one another. Read by
+// rootHandler.
+var memLoadBytes atomic.Int64
+
+const memPageSize = 4096
+
+// MemAction is one step of a memory load plan, analogous to the Action
+// slice used by startCPULoad.
+type MemAction struct {
+	Kind       string // "grow", "hold", "release", "sleep", "thrash"
+	Target     int64
+	Duration   time.Duration
+	ThrashSize int64
+}
+
+// defaultMemPlan is used when -memload.plan is unset or "inline".
+var defaultMemPlan = []MemAction{
+	{Kind: "grow", Target: 512 << 20, Duration: 30 * time.Second},
+	{Kind: "hold", Duration: 2 * time.Minute},
+	{Kind: "release"},
+	{Kind: "sleep", Duration: 5 * time.Minute},
+	{Kind: "thrash", ThrashSize: 64 << 10, Duration: time.Minute},
+	{Kind: "release"},
+	{Kind: "sleep", Duration: 5 * time.Minute},
+}
+
+// parseMemPlan parses the simple line-based plan format:
+//
+//	grow target=512MiB duration=30s
+//	hold duration=2m
+//	thrash size=64KiB duration=1m
+//	release
+//	sleep duration=5m
+//
+// Blank lines and lines starting with "#" are ignored.
+func parseMemPlan(r *bufio.Scanner) ([]MemAction, error) {
+	var plan []MemAction
+	for r.Scan() {
+		line := strings.TrimSpace(r.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		action := MemAction{Kind: fields[0]}
+		for _, kv := range fields[1:] {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				return nil, fmt.Errorf("malformed param %q in line %q", kv, line)
+			}
+			switch k {
+			case "target":
+				n, err := parseByteSize(v)
+				if err != nil {
+					return nil, fmt.Errorf("target: %w", err)
+				}
+				action.Target = n
+			case "size":
+				n, err := parseByteSize(v)
+				if err != nil {
+					return nil, fmt.Errorf("size: %w", err)
+				}
+				action.ThrashSize = n
+			case "duration":
+				d, err := time.ParseDuration(v)
+				if err != nil {
+					return nil, fmt.Errorf("duration: %w", err)
+				}
+				action.Duration = d
+			default:
+				return nil, fmt.Errorf("unknown param %q in line %q", k, line)
+			}
+		}
+		plan = append(plan, action)
+	}
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// ByteSizeFlag is a flag.Value accepting plain byte counts or KiB/MiB/GiB
+// suffixed sizes, e.g. "512MiB".
+type ByteSizeFlag int64
+
+func (b *ByteSizeFlag) String() string { return strconv.FormatInt(int64(*b), 10) }
+
+func (b *ByteSizeFlag) Set(s string) error {
+	n, err := parseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = ByteSizeFlag(n)
+	return nil
+}
+
+// parseByteSize accepts plain byte counts and KiB/MiB/GiB suffixes.
+func parseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "GiB"):
+		mult, s = 1<<30, strings.TrimSuffix(s, "GiB")
+	case strings.HasSuffix(s, "MiB"):
+		mult, s = 1<<20, strings.TrimSuffix(s, "MiB")
+	case strings.HasSuffix(s, "KiB"):
+		mult, s = 1<<10, strings.TrimSuffix(s, "KiB")
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * mult, nil
+}
+
+// loadMemPlan resolves -memload.plan: "" or "inline" selects the built-in
+// default plan, anything else is treated as a path to a plan file.
+func loadMemPlan(spec string) ([]MemAction, error) {
+	if spec == "" || spec == "inline" {
+		return defaultMemPlan, nil
+	}
+	f, err := os.Open(spec)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMemPlan(bufio.NewScanner(f))
+}
+
+// startMemLoad runs a scripted plan of heap allocation, release and
+// GC-thrash steps. Each call tracks its own held bytes in current (so
+// -memload.workers > 1 concurrent instances don't clobber each other's
+// notion of "how much have I grown so far"); memLoadBytes only ever sees
+// relative Add()s from that local total, so it stays an accurate sum across
+// workers instead of a racy absolute value computed from a shared read.
+// release is closed (or nil) to request an early, graceful release of held
+// memory, e.g. on shutdown signal.
+func startMemLoad(plan []MemAction, maxBytes int64, growRate int64, release <-chan struct{}) {
+	testID := xid.New()
+	logger := logger.With().Str("memload.id", testID.String()).Logger()
+	logger.Info().Msg("mem load starts")
+	defer logger.Info().Msg("mem load ended")
+
+	var held [][]byte
+	var current int64
+
+	releaseAll := func() {
+		held = nil
+		memLoadBytes.Add(-current)
+		current = 0
+		logger.Info().Msg("memload release")
+	}
+
+	growTo := func(target int64, duration time.Duration) {
+		if maxBytes > 0 && target > maxBytes {
+			target = maxBytes
+		}
+		if target <= current {
+			return
+		}
+
+		step := time.Second
+
+		// duration, when set, overrides growRate for this step: the
+		// per-tick chunk size is derived so the target is reached in
+		// roughly that time instead of at the default/configured pace.
+		rate := growRate
+		if rate <= 0 {
+			rate = 16 << 20
+		}
+		if duration > 0 {
+			if ticks := int64(duration / step); ticks > 0 {
+				rate = (target - current) / ticks
+			} else {
+				rate = target - current
+			}
+			if rate <= 0 {
+				rate = target - current
+			}
+		}
+
+		for current < target {
+			select {
+			case <-release:
+				releaseAll()
+				return
+			default:
+			}
+			chunk := rate
+			if current+chunk > target {
+				chunk = target - current
+			}
+			buf := make([]byte, chunk)
+			for i := 0; i < len(buf); i += memPageSize {
+				buf[i] = 1
+			}
+			held = append(held, buf)
+			current += chunk
+			memLoadBytes.Add(chunk)
+			logger.Info().Int64("bytes", current).Int64("total_bytes", memLoadBytes.Load()).Msg("memload grow")
+			if current >= target {
+				break
+			}
+			time.Sleep(step)
+		}
+	}
+
+	sleepOrRelease := func(d time.Duration) bool {
+		select {
+		case <-time.After(d):
+			return true
+		case <-release:
+			releaseAll()
+			return false
+		}
+	}
+
+	for i, action := range plan {
+		logger := logger.With().Int("step.#", i).Str("step.kind", action.Kind).Logger()
+		switch action.Kind {
+		case "grow":
+			logger.Info().Int64("target", action.Target).Msg("memload grow to target")
+			growTo(action.Target, action.Duration)
+		case "hold":
+			logger.Info().Dur("duration", action.Duration).Msg("memload hold")
+			if !sleepOrRelease(action.Duration) {
+				return
+			}
+		case "release":
+			releaseAll()
+		case "sleep":
+			logger.Info().Dur("duration", action.Duration).Msg("memload sleep")
+			if !sleepOrRelease(action.Duration) {
+				return
+			}
+		case "thrash":
+			logger.Info().Int64("size", action.ThrashSize).Dur("duration", action.Duration).Msg("memload thrash")
+			size := action.ThrashSize
+			if size <= 0 {
+				size = 64 << 10
+			}
+			end := time.Now().Add(action.Duration)
+			for time.Now().Before(end) {
+				select {
+				case <-release:
+					releaseAll()
+					return
+				default:
+				}
+				buf := make([]byte, size)
+				for i := 0; i < len(buf); i += memPageSize {
+					buf[i] = 1
+				}
+				_ = buf
+			}
+		default:
+			logger.Warn().Msg("unknown memload action, skipping")
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ProbeSettings configures a single probe (live, ready, startup) whose
+// response can be scripted: an initial delay before it reports healthy, a
+// point at which it starts failing, a chance to flake on any given check,
+// and how long a failure sticks once triggered.
+type ProbeSettings struct {
+	Delay         time.Duration
+	FailAfter     time.Duration
+	FlakePercent  int
+	StickyFailFor time.Duration
+	Latency       time.Duration
+	OKCode        int
+	FailCode      int
+}
+
+func (p *ProbeSettings) register(fs *flag.FlagSet, name string) {
+	fs.DurationVar(&p.Delay, "probe."+name+".delay", 0, "delay before "+name+" probe reports healthy")
+	fs.DurationVar(&p.FailAfter, "probe."+name+".fail.after", 0, name+" probe starts failing after this long, 0 disables")
+	fs.IntVar(&p.FlakePercent, "probe."+name+".flake.percent", 0, "% chance "+name+" probe flips its result on any given check")
+	fs.DurationVar(&p.StickyFailFor, "probe."+name+".stickyfail", 0, "once "+name+" probe fails, stay failing for this long")
+	fs.DurationVar(&p.Latency, "probe."+name+".latency", 0, "artificial latency added before responding to "+name+" probe")
+	fs.IntVar(&p.OKCode, "probe."+name+".ok.code", http.StatusOK, "status code returned when "+name+" probe is healthy")
+	fs.IntVar(&p.FailCode, "probe."+name+".fail.code", http.StatusServiceUnavailable, "status code returned when "+name+" probe is unhealthy")
+}
+
+// ProbeState is the running state machine for one probe.
+type ProbeState struct {
+	name     string
+	settings ProbeSettings
+	start    time.Time
+	rng      *lockedRand
+
+	mu        sync.Mutex
+	failUntil time.Time
+	checks    int64
+	fails     int64
+}
+
+// NewProbeState starts a probe's clock at t0, so -probe.<name>.delay and
+// -probe.<name>.fail.after are both measured from process/probe start. rng
+// may be shared across probes (and with other subsystems seeded from the
+// same -rand.seed1/2): lockedRand serializes access since math/rand/v2's
+// Rand is not safe for concurrent use.
+func NewProbeState(name string, settings ProbeSettings, rng *lockedRand) *ProbeState {
+	return &ProbeState{
+		name:     name,
+		settings: settings,
+		start:    time.Now(),
+		rng:      rng,
+	}
+}
+
+// Check evaluates the probe's current health, advancing its internal state
+// machine, and returns whether it is healthy.
+func (p *ProbeState) Check() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.checks++
+	now := time.Now()
+	healthy := p.evaluateLocked(now)
+
+	if !healthy {
+		p.fails++
+		if p.settings.StickyFailFor > 0 && (p.failUntil.IsZero() || !now.Before(p.failUntil)) {
+			p.failUntil = now.Add(p.settings.StickyFailFor)
+		}
+	}
+
+	return healthy
+}
+
+// evaluateLocked reports current health without mutating fail/check
+// counters; callers must hold p.mu.
+func (p *ProbeState) evaluateLocked(now time.Time) bool {
+	elapsed := now.Sub(p.start)
+
+	healthy := elapsed >= p.settings.Delay
+
+	if healthy && !p.failUntil.IsZero() && now.Before(p.failUntil) {
+		healthy = false
+	}
+
+	if healthy && p.settings.FailAfter > 0 && elapsed >= p.settings.FailAfter {
+		healthy = false
+	}
+
+	if healthy && p.settings.FlakePercent > 0 && p.rng.IntN(100) < p.settings.FlakePercent {
+		healthy = false
+	}
+
+	return healthy
+}
+
+// ForceFail makes the probe report unhealthy for at least duration,
+// regardless of its normal delay/fail-after/flake settings. Used by a
+// scenario's probe-flip track.
+func (p *ProbeState) ForceFail(duration time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	until := time.Now().Add(duration)
+	if until.After(p.failUntil) {
+		p.failUntil = until
+	}
+}
+
+// ForceHeal clears any sticky failure, letting the probe's normal settings
+// decide its health again.
+func (p *ProbeState) ForceHeal() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failUntil = time.Time{}
+}
+
+// Status is the JSON shape served at /probe/status for test verification.
+type ProbeStatusEntry struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Checks  int64  `json:"checks"`
+	Fails   int64  `json:"fails"`
+}
+
+func (p *ProbeState) handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if p.settings.Latency > 0 {
+			time.Sleep(p.settings.Latency)
+		}
+		if p.Check() {
+			w.WriteHeader(p.settings.OKCode)
+			return
+		}
+		w.WriteHeader(p.settings.FailCode)
+	}
+}
+
+func probeStatusHandler(probes ...*ProbeState) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entries := make([]ProbeStatusEntry, 0, len(probes))
+		now := time.Now()
+		for _, p := range probes {
+			p.mu.Lock()
+			healthy := now.Sub(p.start) >= p.settings.Delay &&
+				(p.failUntil.IsZero() || now.After(p.failUntil)) &&
+				(p.settings.FailAfter == 0 || now.Sub(p.start) < p.settings.FailAfter)
+			entries = append(entries, ProbeStatusEntry{
+				Name:    p.name,
+				Healthy: healthy,
+				Checks:  p.checks,
+				Fails:   p.fails,
+			})
+			p.mu.Unlock()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"math/rand/v2"
+	"sync"
+)
+
+// lockedRand wraps a *rand.Rand with a mutex so a single seeded source can
+// be shared across concurrent callers. math/rand/v2's Rand is documented as
+// unsafe for concurrent use; without this, fault injection and probe state
+// machines would each need their own generator or race on a shared one,
+// breaking the "-rand.seed1/2 reproduces the same run" guarantee.
+type lockedRand struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newLockedRand(rng *rand.Rand) *lockedRand {
+	return &lockedRand{rng: rng}
+}
+
+func (l *lockedRand) Float64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rng.Float64()
+}
+
+func (l *lockedRand) Int64N(n int64) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rng.Int64N(n)
+}
+
+func (l *lockedRand) IntN(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rng.IntN(n)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// systemdListeners returns listeners inherited via systemd-style socket
+// activation (LISTEN_FDS/LISTEN_PID), or nil if none were passed.
+//
+// The LISTEN_PID check is relaxed to "unset or matches us": startGracefulRestart
+// reuses this same convention for self-restart, and the forking parent has
+// no way to learn the child's pid before it execs, unlike systemd itself
+// which sets LISTEN_PID from inside the freshly forked child.
+func systemdListeners() ([]net.Listener, error) {
+	nfdsStr := os.Getenv("LISTEN_FDS")
+	if nfdsStr == "" {
+		return nil, nil
+	}
+	if pidStr := os.Getenv("LISTEN_PID"); pidStr != "" {
+		if pid, err := strconv.Atoi(pidStr); err == nil && pid != os.Getpid() {
+			return nil, nil
+		}
+	}
+	nfds, err := strconv.Atoi(nfdsStr)
+	if err != nil || nfds <= 0 {
+		return nil, nil
+	}
+
+	listeners := make([]net.Listener, 0, nfds)
+	for i := 0; i < nfds; i++ {
+		fd := 3 + i
+		f := os.NewFile(uintptr(fd), "listener"+strconv.Itoa(i))
+		l, err := net.FileListener(f)
+		if err != nil {
+			return nil, fmt.Errorf("inherited fd %d: %w", fd, err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// resolveListener returns a socket-activated listener when one was passed
+// in, otherwise binds addr directly.
+func resolveListener(addr string) (net.Listener, error) {
+	listeners, err := systemdListeners()
+	if err != nil {
+		return nil, err
+	}
+	if len(listeners) > 0 {
+		logger.Info().Int("fds", len(listeners)).Msg("using socket-activated listener")
+		return listeners[0], nil
+	}
+	return net.Listen("tcp", addr)
+}
+
+// startGracefulRestart waits for SIGHUP on sighup and, on each one, forks a
+// replacement copy of this process, handing it ln's file descriptor via
+// the LISTEN_FDS convention, stops srv from accepting new connections, and
+// drains in-flight requests for up to hammer before the parent exits.
+func startGracefulRestart(sighup <-chan os.Signal, srv *http.Server, ln net.Listener, hammer time.Duration) {
+	for range sighup {
+		logger.Info().Msg("graceful restart: received SIGHUP")
+
+		tcpLn, ok := ln.(*net.TCPListener)
+		if !ok {
+			logger.Error().Msg("graceful restart: listener is not a *net.TCPListener, cannot pass its fd on")
+			continue
+		}
+		lnFile, err := tcpLn.File()
+		if err != nil {
+			logger.Err(err).Msg("graceful restart: could not dup listener fd")
+			continue
+		}
+
+		cmd := exec.Command(os.Args[0], os.Args[1:]...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.ExtraFiles = []*os.File{lnFile}
+		cmd.Env = append(os.Environ(), "LISTEN_FDS=1", "LISTEN_PID=")
+
+		if err := cmd.Start(); err != nil {
+			logger.Err(err).Msg("graceful restart: could not start replacement process")
+			lnFile.Close()
+			continue
+		}
+		logger.Info().Int("pid", cmd.Process.Pid).Msg("graceful restart: replacement started, draining in-flight requests")
+
+		ctx, cancel := context.WithTimeout(context.Background(), hammer)
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.Err(err).Dur("hammer", hammer).Msg("graceful restart: hammer timeout reached, forcing close")
+			srv.Close()
+		}
+		cancel()
+		lnFile.Close()
+
+		logger.Info().Msg("graceful restart: parent exiting")
+		os.Exit(0)
+	}
+}
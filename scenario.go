@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ScenarioStep is one action within a ScenarioTrack's timeline. After is
+// the wait before the step fires for one-shot tracks (exit,
+// signal-ignore-window, probe-flip, http-fault), or the step's own
+// duration for the inherently sequential cpu/mem tracks.
+type ScenarioStep struct {
+	After         time.Duration     `json:"after"`
+	Action        string            `json:"action"`
+	Params        map[string]string `json:"params"`
+	JitterPercent int               `json:"jitter_percent"`
+}
+
+// ScenarioTrack is one concurrent timeline within a Scenario. Repeat==0
+// runs Steps once, Repeat<0 loops forever, Repeat>0 runs that many times.
+type ScenarioTrack struct {
+	Kind   string         `json:"kind"`
+	Repeat int            `json:"repeat"`
+	Steps  []ScenarioStep `json:"steps"`
+}
+
+// Scenario is a timeline of concurrent tracks driving the cpu, mem,
+// http-fault, exit, signal-ignore-window and probe-flip subsystems, loaded
+// via -scenario.file so a seed + scenario yields a reproducible run.
+type Scenario struct {
+	Tracks []ScenarioTrack `json:"tracks"`
+}
+
+// defaultCPUPlan is the CPU load track used when no -scenario.file is
+// given, preserving the original hard-coded progression.
+var defaultCPUPlan = []Action{
+	{Duration: 30 * time.Second, Percent: 90},
+	{Duration: 30 * time.Second},
+	{Duration: 30 * time.Second, Percent: 90},
+	{Duration: 30 * time.Second},
+	{Duration: 6 * time.Minute, Percent: 10},
+	{Duration: 6 * time.Minute},
+	{Duration: 6 * time.Minute, Percent: 20},
+	{Duration: 6 * time.Minute},
+	{Duration: 6 * time.Minute, Percent: 30},
+	{Duration: 6 * time.Minute},
+	{Duration: 6 * time.Minute, Percent: 40},
+	{Duration: 6 * time.Minute},
+	{Duration: 6 * time.Minute, Percent: 50},
+	{Duration: 6 * time.Minute},
+	{Duration: 6 * time.Minute, Percent: 60},
+	{Duration: 6 * time.Minute},
+	{Duration: 6 * time.Minute, Percent: 70},
+	{Duration: 6 * time.Minute},
+	{Duration: 30 * time.Second, Percent: 90},
+	{Duration: 30 * time.Second},
+	{Duration: 30 * time.Second, Percent: 90},
+	{Duration: 6 * time.Minute},
+	{Duration: 6 * time.Minute, Percent: 70},
+	{Duration: 6 * time.Minute, Percent: 50},
+	{Duration: 6 * time.Minute, Percent: 20},
+	{Duration: 30 * time.Second},
+	{Duration: 30 * time.Second, Percent: 90},
+	{Duration: 10 * time.Minute},
+	{Duration: 30 * time.Second, Percent: 90},
+	{Duration: 10 * time.Minute},
+	{Duration: 30 * time.Second, Percent: 90},
+	{Duration: 6 * time.Minute},
+	{Duration: 30 * time.Second, Percent: 50},
+	{Duration: 6 * time.Minute},
+	{Duration: 30 * time.Second, Percent: 80},
+	{Duration: 6 * time.Minute},
+	{Duration: 30 * time.Second, Percent: 70},
+	{Duration: 10 * time.Minute},
+}
+
+// defaultScenario wraps defaultCPUPlan as a single cpu track, so
+// -scenario.file="inline" reproduces the original fixed chaos program.
+func defaultScenario() Scenario {
+	steps := make([]ScenarioStep, len(defaultCPUPlan))
+	for i, a := range defaultCPUPlan {
+		steps[i] = ScenarioStep{
+			After:  a.Duration,
+			Action: "busy",
+			Params: map[string]string{"percent": strconv.Itoa(a.Percent)},
+		}
+	}
+	return Scenario{Tracks: []ScenarioTrack{{Kind: "cpu", Steps: steps}}}
+}
+
+// LoadScenario resolves -scenario.file: "" or "inline" selects
+// defaultScenario, a path ending in ".json" is parsed as JSON, anything
+// else as the line-based format documented on ParseScenarioLines.
+func LoadScenario(spec string) (Scenario, error) {
+	if spec == "" || spec == "inline" {
+		return defaultScenario(), nil
+	}
+	f, err := os.Open(spec)
+	if err != nil {
+		return Scenario{}, err
+	}
+	defer f.Close()
+	if strings.HasSuffix(spec, ".json") {
+		var s Scenario
+		err := json.NewDecoder(f).Decode(&s)
+		return s, err
+	}
+	return ParseScenarioLines(f)
+}
+
+// ParseScenarioLines parses the line-based scenario format, e.g.:
+//
+//	track cpu repeat=0
+//	  after=30s action=busy percent=90
+//	  after=30s action=sleep
+//	end
+//
+//	track exit
+//	  after=5m action=exit percent=10 code=1
+//	end
+//
+// Blank lines and lines starting with "#" are ignored.
+func ParseScenarioLines(r io.Reader) (Scenario, error) {
+	var scenario Scenario
+	var current *ScenarioTrack
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+
+		switch {
+		case fields[0] == "end":
+			if current == nil {
+				return scenario, fmt.Errorf("unexpected end outside of track block")
+			}
+			scenario.Tracks = append(scenario.Tracks, *current)
+			current = nil
+		case fields[0] == "track":
+			if current != nil {
+				return scenario, fmt.Errorf("nested track blocks are not supported")
+			}
+			if len(fields) < 2 {
+				return scenario, fmt.Errorf("track line missing kind: %q", line)
+			}
+			track := ScenarioTrack{Kind: fields[1]}
+			for _, kv := range fields[2:] {
+				k, v, ok := strings.Cut(kv, "=")
+				if !ok || k != "repeat" {
+					return scenario, fmt.Errorf("unexpected track param %q", kv)
+				}
+				n, err := strconv.Atoi(v)
+				if err != nil {
+					return scenario, fmt.Errorf("repeat: %w", err)
+				}
+				track.Repeat = n
+			}
+			current = &track
+		default:
+			if current == nil {
+				return scenario, fmt.Errorf("step outside of track block: %q", line)
+			}
+			step := ScenarioStep{Params: map[string]string{}}
+			for _, kv := range fields {
+				k, v, ok := strings.Cut(kv, "=")
+				if !ok {
+					return scenario, fmt.Errorf("malformed step param %q in line %q", kv, line)
+				}
+				switch k {
+				case "after":
+					d, err := time.ParseDuration(v)
+					if err != nil {
+						return scenario, fmt.Errorf("after: %w", err)
+					}
+					step.After = d
+				case "action":
+					step.Action = v
+				case "jitter.percent":
+					n, err := strconv.Atoi(v)
+					if err != nil {
+						return scenario, fmt.Errorf("jitter.percent: %w", err)
+					}
+					step.JitterPercent = n
+				default:
+					step.Params[k] = v
+				}
+			}
+			current.Steps = append(current.Steps, step)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return scenario, err
+	}
+	if current != nil {
+		return scenario, fmt.Errorf("track %q missing closing end", current.Kind)
+	}
+	return scenario, nil
+}
+
+// ScenarioDeps are the already-running subsystems a Scenario's tracks can
+// reach into. Probes and FaultRules are nil/empty when -web.enable=false,
+// since the scenario engine itself runs regardless of the web subsystem;
+// probe-flip and http-fault steps detect that and log-and-skip rather than
+// panicking.
+type ScenarioDeps struct {
+	Rand       *rand.Rand
+	Probes     map[string]*ProbeState
+	FaultRules *atomic.Pointer[[]FaultRule]
+	ExitCode   int
+}
+
+// RunScenario starts one goroutine per track and returns immediately; the
+// tracks run concurrently and independently for the lifetime of the
+// process.
+func RunScenario(scenario Scenario, deps ScenarioDeps) {
+	for _, track := range scenario.Tracks {
+		go runTrack(track, deps)
+	}
+}
+
+func jitter(rng *rand.Rand, d time.Duration, percent int) time.Duration {
+	if percent == 0 || d == 0 {
+		return d
+	}
+	spread := time.Duration(int64(d) * int64(percent) / 100)
+	return max(0, d+time.Duration(rng.Int64N(2*int64(spread)))-spread)
+}
+
+// trackRepeatCount returns how many times a track's Steps should run for a
+// non-negative Repeat: 0 means once, N>0 means N times. Repeat<0 (loop
+// forever) is handled separately by callers via `track.Repeat < 0` in the
+// loop condition, short-circuiting before this is ever consulted.
+func trackRepeatCount(repeat int) int {
+	if repeat <= 0 {
+		return 1
+	}
+	return repeat
+}
+
+func atoiOr(s string, fallback int) int {
+	if s == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fallback
+	}
+	return n
+}
+
+func runTrack(track ScenarioTrack, deps ScenarioDeps) {
+	logger := logger.With().Str("scenario.track", track.Kind).Logger()
+
+	switch track.Kind {
+	case "cpu":
+		plan := make([]Action, len(track.Steps))
+		for i, s := range track.Steps {
+			plan[i] = Action{Duration: s.After, Percent: atoiOr(s.Params["percent"], 0)}
+		}
+		for i := 0; track.Repeat < 0 || i < trackRepeatCount(track.Repeat); i++ {
+			startCPULoad(plan)
+		}
+		return
+	case "mem":
+		plan := make([]MemAction, len(track.Steps))
+		for i, s := range track.Steps {
+			plan[i] = MemAction{Kind: s.Action, Duration: s.After}
+			if target, err := parseByteSize(s.Params["target"]); err == nil {
+				plan[i].Target = target
+			}
+			if size, err := parseByteSize(s.Params["size"]); err == nil {
+				plan[i].ThrashSize = size
+			}
+		}
+		release := make(chan struct{})
+		for i := 0; track.Repeat < 0 || i < trackRepeatCount(track.Repeat); i++ {
+			startMemLoad(plan, 0, 0, release)
+		}
+		return
+	}
+
+	for i := 0; track.Repeat < 0 || i < trackRepeatCount(track.Repeat); i++ {
+		for _, step := range track.Steps {
+			time.Sleep(jitter(deps.Rand, step.After, step.JitterPercent))
+			runOneShotStep(logger, track.Kind, step, deps)
+		}
+	}
+}
+
+// startSignalIgnoreWindow opens a window during which shutdown signals are
+// caught and logged rather than left to their default disposition, closing
+// the window again after duration.
+func startSignalIgnoreWindow(logger zerolog.Logger, duration time.Duration) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c,
+		syscall.SIGABRT,
+		syscall.SIGHUP,
+		syscall.SIGINT,
+		syscall.SIGPIPE,
+		syscall.SIGTERM,
+	)
+	defer signal.Stop(c)
+
+	logger.Info().Dur("duration", duration).Msg("scenario: signal-ignore window opened")
+	deadline := time.After(duration)
+	for {
+		select {
+		case s := <-c:
+			logger.Info().Stringer("signal", s).Msg("scenario: ignoring signal in window")
+		case <-deadline:
+			logger.Info().Msg("scenario: signal-ignore window closed")
+			return
+		}
+	}
+}
+
+func runOneShotStep(logger zerolog.Logger, kind string, step ScenarioStep, deps ScenarioDeps) {
+	switch kind {
+	case "exit":
+		percent := atoiOr(step.Params["percent"], 100)
+		code := atoiOr(step.Params["code"], deps.ExitCode)
+		if percent >= 100 || deps.Rand.IntN(100) < percent {
+			logger.Info().Msg("scenario: exit step triggered")
+			os.Exit(code)
+		}
+	case "signal-ignore-window":
+		d, err := time.ParseDuration(step.Params["duration"])
+		if err != nil {
+			logger.Err(err).Msg("scenario: signal-ignore-window missing duration")
+			return
+		}
+		startSignalIgnoreWindow(logger, d)
+	case "probe-flip":
+		probe := deps.Probes[step.Params["probe"]]
+		if probe == nil {
+			logger.Warn().Str("probe", step.Params["probe"]).Msg("scenario: probe unavailable (unknown name, or web.enable=false), skipping step")
+			return
+		}
+		switch step.Action {
+		case "fail":
+			d, _ := time.ParseDuration(step.Params["duration"])
+			probe.ForceFail(d)
+		case "heal":
+			probe.ForceHeal()
+		default:
+			logger.Warn().Str("action", step.Action).Msg("scenario: unknown probe-flip action")
+		}
+	case "http-fault":
+		if deps.FaultRules == nil {
+			logger.Warn().Msg("scenario: http-fault unavailable with web.enable=false, skipping step")
+			return
+		}
+		switch step.Action {
+		case "load":
+			rules, err := LoadFaultRules(step.Params["file"])
+			if err != nil {
+				logger.Err(err).Msg("scenario: could not load fault rules")
+				return
+			}
+			deps.FaultRules.Store(&rules)
+			logger.Info().Int("rules", len(rules)).Msg("scenario: fault rules loaded")
+		case "clear":
+			empty := []FaultRule{}
+			deps.FaultRules.Store(&empty)
+		default:
+			logger.Warn().Str("action", step.Action).Msg("scenario: unknown http-fault action")
+		}
+	default:
+		logger.Warn().Msg("scenario: unknown track kind")
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestTrackRepeatCount(t *testing.T) {
+	cases := []struct {
+		repeat int
+		want   int
+	}{
+		{repeat: 0, want: 1},
+		{repeat: 1, want: 1},
+		{repeat: 2, want: 2},
+		{repeat: 5, want: 5},
+	}
+	for _, c := range cases {
+		if got := trackRepeatCount(c.repeat); got != c.want {
+			t.Errorf("trackRepeatCount(%d) = %d, want %d", c.repeat, got, c.want)
+		}
+	}
+}